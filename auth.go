@@ -0,0 +1,65 @@
+// auth.go
+// Pre-upgrade authentication: a WebSocket handshake carries no body and
+// browsers can't set arbitrary headers on it, so the bearer token travels
+// either as a normal Authorization header (non-browser clients) or as the
+// "bearer,<jwt>" Sec-WebSocket-Protocol pair (browsers), per the convention
+// used by most JWT-over-WebSocket examples.
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+)
+
+// userClaims are the JWT claims this server understands. Subject becomes
+// the client's identity.
+type userClaims struct {
+	jwt.RegisteredClaims
+}
+
+// authenticate extracts and validates the bearer token from r. When no JWT
+// key is configured, auth is treated as disabled (local/dev mode) and an
+// empty userID is returned rather than an error. Once a key is configured,
+// a missing or invalid token is an error so wsHandler can reject the
+// upgrade with 401.
+func authenticate(r *http.Request, cfg serverConfig) (userID string, err error) {
+	if !cfg.jwtEnabled() {
+		return "", nil
+	}
+
+	token := bearerToken(r)
+	if token == "" {
+		return "", errors.New("missing bearer token")
+	}
+
+	claims := &userClaims{}
+	parsed, err := jwt.ParseWithClaims(token, claims, cfg.jwtKeyFunc)
+	if err != nil || !parsed.Valid {
+		return "", errors.New("invalid or expired token")
+	}
+	if claims.Subject == "" {
+		return "", errors.New("token missing sub claim")
+	}
+
+	return claims.Subject, nil
+}
+
+// bearerToken extracts the JWT from the Authorization header, falling back
+// to the "bearer,<jwt>" WebSocket subprotocol pair.
+func bearerToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+
+	protocols := websocket.Subprotocols(r)
+	for i, p := range protocols {
+		if p == "bearer" && i+1 < len(protocols) {
+			return protocols[i+1]
+		}
+	}
+	return ""
+}
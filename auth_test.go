@@ -0,0 +1,137 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func signHS256(t *testing.T, secret []byte, claims jwt.Claims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := token.SignedString(secret)
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+	return signed
+}
+
+func TestAuthenticateDisabledReturnsEmptyUserID(t *testing.T) {
+	r, _ := http.NewRequest(http.MethodGet, "/ws", nil)
+
+	userID, err := authenticate(r, serverConfig{})
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if userID != "" {
+		t.Fatalf("got userID %q, want empty", userID)
+	}
+}
+
+func TestAuthenticateMissingTokenIsError(t *testing.T) {
+	cfg := serverConfig{jwtHMACSecret: []byte("secret")}
+	r, _ := http.NewRequest(http.MethodGet, "/ws", nil)
+
+	if _, err := authenticate(r, cfg); err == nil {
+		t.Fatal("expected an error for a missing bearer token")
+	}
+}
+
+func TestAuthenticateValidTokenReturnsSubject(t *testing.T) {
+	secret := []byte("secret")
+	cfg := serverConfig{jwtHMACSecret: secret}
+
+	token := signHS256(t, secret, jwt.RegisteredClaims{
+		Subject:   "user1",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	})
+
+	r, _ := http.NewRequest(http.MethodGet, "/ws", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+
+	userID, err := authenticate(r, cfg)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if userID != "user1" {
+		t.Fatalf("got userID %q, want %q", userID, "user1")
+	}
+}
+
+func TestAuthenticateMissingSubjectIsError(t *testing.T) {
+	secret := []byte("secret")
+	cfg := serverConfig{jwtHMACSecret: secret}
+
+	token := signHS256(t, secret, jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	})
+
+	r, _ := http.NewRequest(http.MethodGet, "/ws", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+
+	if _, err := authenticate(r, cfg); err == nil {
+		t.Fatal("expected an error for a token with no sub claim")
+	}
+}
+
+func TestAuthenticateWrongSecretIsError(t *testing.T) {
+	cfg := serverConfig{jwtHMACSecret: []byte("secret")}
+
+	token := signHS256(t, []byte("wrong-secret"), jwt.RegisteredClaims{
+		Subject:   "user1",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	})
+
+	r, _ := http.NewRequest(http.MethodGet, "/ws", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+
+	if _, err := authenticate(r, cfg); err == nil {
+		t.Fatal("expected an error for a token signed with a different secret")
+	}
+}
+
+func TestAuthenticateExpiredTokenIsError(t *testing.T) {
+	secret := []byte("secret")
+	cfg := serverConfig{jwtHMACSecret: secret}
+
+	token := signHS256(t, secret, jwt.RegisteredClaims{
+		Subject:   "user1",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+	})
+
+	r, _ := http.NewRequest(http.MethodGet, "/ws", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+
+	if _, err := authenticate(r, cfg); err == nil {
+		t.Fatal("expected an error for an expired token")
+	}
+}
+
+func TestBearerTokenFromAuthorizationHeader(t *testing.T) {
+	r, _ := http.NewRequest(http.MethodGet, "/ws", nil)
+	r.Header.Set("Authorization", "Bearer abc.def.ghi")
+
+	if got := bearerToken(r); got != "abc.def.ghi" {
+		t.Fatalf("got %q, want %q", got, "abc.def.ghi")
+	}
+}
+
+func TestBearerTokenFromSubprotocol(t *testing.T) {
+	r, _ := http.NewRequest(http.MethodGet, "/ws", nil)
+	r.Header.Set("Sec-WebSocket-Protocol", "bearer, abc.def.ghi")
+
+	if got := bearerToken(r); got != "abc.def.ghi" {
+		t.Fatalf("got %q, want %q", got, "abc.def.ghi")
+	}
+}
+
+func TestBearerTokenMissingReturnsEmpty(t *testing.T) {
+	r, _ := http.NewRequest(http.MethodGet, "/ws", nil)
+
+	if got := bearerToken(r); got != "" {
+		t.Fatalf("got %q, want empty", got)
+	}
+}
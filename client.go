@@ -1,22 +1,50 @@
 // Client.go
-// The read goroutine listens to messages from the browser and pushes them into manager.broadcast.
-// The write goroutine drains the client’s send channel back to the browser.
+// The read goroutine listens to messages from the browser: control frames (subscribe/
+// unsubscribe/publish) update topic membership or publish scoped to a topic, direct
+// messages are routed to a single recipient, and anything else lands in the "general"
+// room. The write goroutine drains the client’s send channel back to the browser and pings it on
+// pingPeriod; if no pong arrives within pongWait the read deadline trips and the connection
+// is torn down, so dead peers are evicted instead of leaking goroutines forever.
 // Separating read/write avoids head-of-line blocking when a browser is slow.
 
 package main
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
 
+const (
+	// writeWait is the time allowed to write a message to the peer.
+	writeWait = 10 * time.Second
+
+	// pongWait is the time allowed to read the next pong message from the peer.
+	pongWait = 60 * time.Second
+
+	// pingPeriod sends pings to the peer with this period. Must be less than pongWait.
+	pingPeriod = (pongWait * 9) / 10
+
+	// maxMessageSize is the maximum message size allowed from the peer.
+	maxMessageSize = 8192
+)
+
+var newline = []byte{'\n'}
+
 func (c *Client) read() {
 	defer func() {
 		manager.unregister <- c
 		c.socket.Close()
 	}()
 
+	c.socket.SetReadLimit(maxMessageSize)
+	c.socket.SetReadDeadline(time.Now().Add(pongWait))
+	c.socket.SetPongHandler(func(string) error {
+		c.socket.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+
 	for {
 		_, message, err := c.socket.ReadMessage()
 		if err != nil {
@@ -24,17 +52,83 @@ func (c *Client) read() {
 			c.socket.Close()
 			break
 		}
-		jsonMessage, _ := json.Marshal(&Message{Sender: c.id, Content: string(message)})
-		manager.broadcast <- jsonMessage
+
+		var ctrl controlMessage
+		json.Unmarshal(message, &ctrl)
+
+		switch ctrl.Action {
+		case "subscribe":
+			manager.subscribe <- topicRequest{topic: ctrl.Topic, client: c}
+			continue
+		case "unsubscribe":
+			manager.unsubscribe <- topicRequest{topic: ctrl.Topic, client: c}
+			continue
+		case "publish":
+			jsonMessage, _ := json.Marshal(&Message{Type: "broadcast", Sender: c.id, Content: ctrl.Content})
+			manager.publish <- topicMessage{topic: ctrl.Topic, message: jsonMessage}
+			continue
+		}
+
+		var inbound Message
+		json.Unmarshal(message, &inbound)
+
+		if inbound.Recipient != "" {
+			manager.direct <- &Message{Type: "dm", Sender: c.id, Recipient: inbound.Recipient, Content: inbound.Content}
+			continue
+		}
+
+		jsonMessage, _ := json.Marshal(&Message{Type: "broadcast", Sender: c.id, Content: inbound.Content})
+		manager.publish <- topicMessage{topic: generalTopic, message: jsonMessage}
 
 	}
 }
 
 func (c *Client) write() {
-	defer c.socket.Close()
+	if serverCfg.enableCompression {
+		c.socket.EnableWriteCompression(true)
+		c.socket.SetCompressionLevel(serverCfg.compressionLevel)
+	}
+
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.socket.Close()
+	}()
+
+	for {
+		select {
+		case message, ok := <-c.send:
+			c.socket.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				// manager closed the channel, either because the client
+				// disconnected or the server is shutting down.
+				c.socket.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""))
+				return
+			}
 
-	for message := range c.send {
-		c.socket.WriteMessage(websocket.TextMessage, message)
+			w, err := c.socket.NextWriter(websocket.TextMessage)
+			if err != nil {
+				return
+			}
+			w.Write(message)
+
+			// Drain any messages that queued up while we were writing, batching
+			// them into a single frame separated by newlines.
+			n := len(c.send)
+			for i := 0; i < n; i++ {
+				w.Write(newline)
+				w.Write(<-c.send)
+			}
+
+			if err := w.Close(); err != nil {
+				return
+			}
+		case <-ticker.C:
+			manager.presence.Mark(c.id)
+			c.socket.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.socket.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
 	}
-	c.socket.WriteMessage(websocket.CloseMessage, []byte{})
 }
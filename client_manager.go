@@ -1,26 +1,78 @@
 // client_manager.go
 package main
 
-import "github.com/gorilla/websocket"
+import (
+	"sync"
 
-// ClientManager tracks connected clients and broadcast traffic.
+	"github.com/gorilla/websocket"
+
+	"go-chat-server/internal/broker"
+	"go-chat-server/internal/hub"
+)
+
+// generalTopic is the default room every client is subscribed to on
+// connect, so plain (non-topic) chat keeps working as a single global room.
+const generalTopic = "general"
+
+// dmTopicPrefix namespaces the per-user topic each client subscribes to on
+// connect, so direct messages can be routed through the same
+// Broker/Hub machinery as topic chat instead of only resolving against
+// this node's local clientsByID - see dmTopic in manager.go.
+const dmTopicPrefix = "dm:"
+
+// ClientManager tracks connected clients and routes direct, system, and
+// topic-scoped traffic between them.
 type ClientManager struct {
-    clients    map[*Client]bool
-    broadcast  chan []byte
-    register   chan *Client
-    unregister chan *Client
+	clients      map[*Client]bool
+	clientsByID  map[string]map[*Client]bool
+	topics       *hub.Hub
+	broker       broker.Broker
+	presence     broker.Presence
+	brokerTopics map[string]bool
+	direct       chan *Message
+	publish      chan topicMessage
+	subscribe    chan topicRequest
+	unsubscribe  chan topicRequest
+	register     chan *Client
+	unregister   chan *Client
+	wg           sync.WaitGroup
 }
 
-// Client represents a single WebSocket connection.
+// Client represents a single WebSocket connection. id is the routing key
+// used throughout ClientManager: the JWT subject when handshake
+// authentication is enabled, otherwise a random per-connection id.
 type Client struct {
-    id     string
-    socket *websocket.Conn
-    send   chan []byte
+	id     string
+	socket *websocket.Conn
+	send   chan []byte
 }
 
 // Message is the JSON payload exchanged between server and UI.
 type Message struct {
-    Sender    string `json:"sender,omitempty"`
-    Recipient string `json:"recipient,omitempty"`
-    Content   string `json:"content,omitempty"`
-}
\ No newline at end of file
+	Type      string `json:"type,omitempty"`
+	Sender    string `json:"sender,omitempty"`
+	Recipient string `json:"recipient,omitempty"`
+	Content   string `json:"content,omitempty"`
+}
+
+// controlMessage is the inbound envelope for subscribe/unsubscribe/publish
+// control frames, as distinct from a plain chat Message.
+type controlMessage struct {
+	Action  string `json:"action,omitempty"`
+	Topic   string `json:"topic,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+// topicRequest is sent on ClientManager.subscribe/unsubscribe to add or
+// remove a client from a topic.
+type topicRequest struct {
+	topic  string
+	client *Client
+}
+
+// topicMessage is sent on ClientManager.publish to scope a message to the
+// subscribers of a single topic.
+type topicMessage struct {
+	topic   string
+	message []byte
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"go-chat-server/internal/broker"
+	"go-chat-server/internal/hub"
+)
+
+func newTestManager() *ClientManager {
+	return &ClientManager{
+		clients:      make(map[*Client]bool),
+		clientsByID:  make(map[string]map[*Client]bool),
+		topics:       hub.New(),
+		broker:       broker.NewMemory(),
+		presence:     broker.NewMemoryPresence(),
+		brokerTopics: make(map[string]bool),
+	}
+}
+
+func registerTestClient(m *ClientManager, id string) *Client {
+	c := &Client{id: id, send: make(chan []byte, 1)}
+	m.clients[c] = true
+	if m.clientsByID[id] == nil {
+		m.clientsByID[id] = make(map[*Client]bool)
+	}
+	m.clientsByID[id][c] = true
+	m.topics.Subscribe(dmTopic(id), c.send)
+	m.ensureBrokerSubscription(dmTopic(id))
+	m.presence.Mark(id)
+	return c
+}
+
+// awaitMessage waits for a message on ch, since route() delivers via the
+// Broker's subscription bridge goroutine rather than writing to send
+// directly.
+func awaitMessage(t *testing.T, ch chan []byte) []byte {
+	t.Helper()
+
+	select {
+	case msg := <-ch:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for a message")
+		return nil
+	}
+}
+
+func assertNoMessage(t *testing.T, ch chan []byte) {
+	t.Helper()
+
+	select {
+	case msg := <-ch:
+		t.Fatalf("got unexpected message %q", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestRouteDeliversToEveryConnectionOfARecipient(t *testing.T) {
+	m := newTestManager()
+	tabA := registerTestClient(m, "user1")
+	tabB := registerTestClient(m, "user1")
+	sender := registerTestClient(m, "user2")
+
+	m.route(&Message{Sender: "user2", Recipient: "user1", Content: "hi"})
+
+	awaitMessage(t, tabA.send)
+	awaitMessage(t, tabB.send)
+	awaitMessage(t, sender.send)
+}
+
+func TestRouteDoesNotDoubleEchoWhenSenderIsRecipient(t *testing.T) {
+	m := newTestManager()
+	self := registerTestClient(m, "user1")
+
+	m.route(&Message{Sender: "user1", Recipient: "user1", Content: "note to self"})
+
+	awaitMessage(t, self.send)
+	assertNoMessage(t, self.send)
+}
+
+func TestRouteDoesNotReachUnrelatedUsers(t *testing.T) {
+	m := newTestManager()
+	recipient := registerTestClient(m, "user1")
+	bystander := registerTestClient(m, "user3")
+
+	m.route(&Message{Sender: "user2", Recipient: "user1", Content: "hi"})
+
+	awaitMessage(t, recipient.send)
+	assertNoMessage(t, bystander.send)
+}
+
+func TestSendSkipsIgnoredClientAndEvictsFullBuffer(t *testing.T) {
+	m := newTestManager()
+	ignored := registerTestClient(m, "user1")
+	stalled := registerTestClient(m, "user2")
+	stalled.send <- []byte("already queued")
+	ok := registerTestClient(m, "user3")
+
+	m.send([]byte("join"), ignored)
+
+	assertNoMessage(t, ignored.send)
+
+	if _, stillConnected := m.clients[stalled]; stillConnected {
+		t.Error("expected a connection with a full send buffer to be evicted")
+	}
+
+	select {
+	case <-ok.send:
+	default:
+		t.Fatal("expected a connection with room in its buffer to receive the broadcast")
+	}
+}
+
+func TestRemoveClientKeepsPresenceUntilLastConnectionCloses(t *testing.T) {
+	m := newTestManager()
+	tabA := registerTestClient(m, "user1")
+	tabB := registerTestClient(m, "user1")
+
+	m.removeClient(tabA)
+
+	if _, ok := m.clientsByID["user1"][tabB]; !ok {
+		t.Fatal("expected the still-connected tab to remain routable")
+	}
+	users, _ := m.presence.Users()
+	if len(users) != 1 || users[0] != "user1" {
+		t.Fatalf("got presence %v, want [user1] while a sibling connection is still open", users)
+	}
+
+	m.removeClient(tabB)
+
+	if _, ok := m.clientsByID["user1"]; ok {
+		t.Error("expected the user's clientsByID entry to be gone once its last connection closes")
+	}
+	users, _ = m.presence.Users()
+	if len(users) != 0 {
+		t.Fatalf("got presence %v, want none once the last connection closes", users)
+	}
+}
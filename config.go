@@ -0,0 +1,143 @@
+// config.go
+// Upgrader and buffer tuning used to be hardcoded; serverConfig pulls it out
+// into flags (with environment variable defaults) so deployments can tune
+// buffer sizes, handshake timeouts, compression, and allowed origins without
+// a rebuild.
+package main
+
+import (
+	"crypto/rsa"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// serverConfig controls the HTTP listener, WebSocket upgrader, and
+// handshake authentication.
+type serverConfig struct {
+	addr              string
+	readBufferSize    int
+	writeBufferSize   int
+	handshakeTimeout  time.Duration
+	subprotocols      []string
+	enableCompression bool
+	compressionLevel  int
+	allowedOrigins    []string
+	jwtHMACSecret     []byte
+	jwtRSAPublicKey   *rsa.PublicKey
+}
+
+// jwtEnabled reports whether a JWT key has been configured. When it hasn't,
+// wsHandler accepts connections without authentication (local/dev mode).
+func (cfg serverConfig) jwtEnabled() bool {
+	return len(cfg.jwtHMACSecret) > 0 || cfg.jwtRSAPublicKey != nil
+}
+
+// jwtKeyFunc is a jwt.Keyfunc that accepts HS256 against jwtHMACSecret or
+// RS256 against jwtRSAPublicKey, whichever is configured, and rejects any
+// other signing method.
+func (cfg serverConfig) jwtKeyFunc(token *jwt.Token) (interface{}, error) {
+	switch token.Method {
+	case jwt.SigningMethodHS256:
+		if len(cfg.jwtHMACSecret) == 0 {
+			return nil, errors.New("HMAC tokens are not accepted")
+		}
+		return cfg.jwtHMACSecret, nil
+	case jwt.SigningMethodRS256:
+		if cfg.jwtRSAPublicKey == nil {
+			return nil, errors.New("RS256 tokens are not accepted")
+		}
+		return cfg.jwtRSAPublicKey, nil
+	default:
+		return nil, errors.New("unexpected signing method")
+	}
+}
+
+// loadConfig parses flags, falling back to environment variables and then
+// to sane defaults for each setting.
+func loadConfig() serverConfig {
+	cfg := serverConfig{}
+
+	flag.StringVar(&cfg.addr, "addr", envOrDefault("ADDR", ":12345"), "HTTP listen address")
+	flag.IntVar(&cfg.readBufferSize, "read-buffer-size", envOrDefaultInt("WS_READ_BUFFER_SIZE", 4096), "WebSocket read buffer size in bytes")
+	flag.IntVar(&cfg.writeBufferSize, "write-buffer-size", envOrDefaultInt("WS_WRITE_BUFFER_SIZE", 4096), "WebSocket write buffer size in bytes")
+	flag.DurationVar(&cfg.handshakeTimeout, "handshake-timeout", envOrDefaultDuration("WS_HANDSHAKE_TIMEOUT", 10*time.Second), "timeout for the WebSocket upgrade handshake")
+	flag.BoolVar(&cfg.enableCompression, "enable-compression", envOrDefaultBool("WS_ENABLE_COMPRESSION", true), "negotiate RFC 7692 permessage-deflate")
+	flag.IntVar(&cfg.compressionLevel, "compression-level", envOrDefaultInt("WS_COMPRESSION_LEVEL", 1), "flate compression level (1=fastest, 9=smallest)")
+
+	subprotocols := flag.String("subprotocols", os.Getenv("WS_SUBPROTOCOLS"), "comma-separated list of accepted WebSocket subprotocols")
+	allowedOrigins := flag.String("allowed-origins", os.Getenv("WS_ALLOWED_ORIGINS"), "comma-separated list of allowed Origin header values (same-origin only if empty)")
+	jwtHMACSecret := flag.String("jwt-hmac-secret", os.Getenv("JWT_HMAC_SECRET"), "HMAC secret used to validate HS256 bearer tokens; leave empty to accept RS256 or disable auth")
+	jwtRSAPublicKeyPath := flag.String("jwt-rsa-public-key", os.Getenv("JWT_RSA_PUBLIC_KEY_PATH"), "path to a PEM-encoded RSA public key used to validate RS256 bearer tokens")
+
+	flag.Parse()
+
+	cfg.subprotocols = splitCSV(*subprotocols)
+	cfg.allowedOrigins = splitCSV(*allowedOrigins)
+	cfg.jwtHMACSecret = []byte(*jwtHMACSecret)
+
+	if *jwtRSAPublicKeyPath != "" {
+		pemBytes, err := os.ReadFile(*jwtRSAPublicKeyPath)
+		if err != nil {
+			fmt.Println("failed to read JWT RSA public key, RS256 tokens will be rejected:", err)
+		} else if key, err := jwt.ParseRSAPublicKeyFromPEM(pemBytes); err != nil {
+			fmt.Println("failed to parse JWT RSA public key, RS256 tokens will be rejected:", err)
+		} else {
+			cfg.jwtRSAPublicKey = key
+		}
+	}
+
+	return cfg
+}
+
+func splitCSV(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	parts := strings.Split(s, ",")
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func envOrDefault(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func envOrDefaultInt(key string, fallback int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+func envOrDefaultBool(key string, fallback bool) bool {
+	v, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+func envOrDefaultDuration(key string, fallback time.Duration) time.Duration {
+	v, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestSplitCSV(t *testing.T) {
+	cases := map[string][]string{
+		"":             nil,
+		"a":            {"a"},
+		"a,b,c":        {"a", "b", "c"},
+		" a , b ,, c ": {"a", "b", "c"},
+	}
+
+	for in, want := range cases {
+		if got := splitCSV(in); !reflect.DeepEqual(got, want) {
+			t.Errorf("splitCSV(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestCheckOriginNoAllowlistFallsBackToSameOrigin(t *testing.T) {
+	check := checkOrigin(nil)
+
+	r, _ := http.NewRequest(http.MethodGet, "http://example.com/ws", nil)
+	r.Host = "example.com"
+
+	r.Header.Set("Origin", "http://example.com")
+	if !check(r) {
+		t.Error("expected same-origin request to be allowed")
+	}
+
+	r.Header.Set("Origin", "http://evil.com")
+	if check(r) {
+		t.Error("expected cross-origin request to be rejected")
+	}
+}
+
+func TestCheckOriginNoOriginHeaderIsAllowed(t *testing.T) {
+	check := checkOrigin([]string{"http://example.com"})
+
+	r, _ := http.NewRequest(http.MethodGet, "http://example.com/ws", nil)
+	if !check(r) {
+		t.Error("expected a request with no Origin header to be allowed")
+	}
+}
+
+func TestCheckOriginAllowlist(t *testing.T) {
+	check := checkOrigin([]string{"http://allowed.com"})
+
+	r, _ := http.NewRequest(http.MethodGet, "http://example.com/ws", nil)
+	r.Host = "example.com"
+
+	r.Header.Set("Origin", "http://allowed.com")
+	if !check(r) {
+		t.Error("expected an allowlisted origin to be allowed")
+	}
+
+	r.Header.Set("Origin", "http://example.com")
+	if check(r) {
+		t.Error("expected an origin not on the allowlist to be rejected, even if same-origin")
+	}
+}
@@ -0,0 +1,32 @@
+// Package broker defines the pluggable message bus that lets a ClientManager
+// fan broadcast traffic out across multiple server instances instead of
+// being limited to the sockets connected to a single process.
+package broker
+
+// Broker is implemented by every message bus backend. Publish/Subscribe are
+// scoped by topic so a single Broker instance can back many rooms.
+type Broker interface {
+	// Publish sends msg to every subscriber of topic, on this node and any
+	// other node sharing the same backend.
+	Publish(topic string, msg []byte) error
+
+	// Subscribe returns a channel that receives every message published to
+	// topic, from any node. The channel is closed when the Broker is closed.
+	Subscribe(topic string) (<-chan []byte, error)
+
+	// Close releases the Broker's resources.
+	Close() error
+}
+
+// Presence tracks which user ids are currently online across the cluster.
+type Presence interface {
+	// Mark records userID as online, refreshing its TTL if the backend
+	// expires entries.
+	Mark(userID string) error
+
+	// Remove records userID as offline.
+	Remove(userID string) error
+
+	// Users returns the ids of every user currently online.
+	Users() ([]string, error)
+}
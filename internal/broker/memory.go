@@ -0,0 +1,96 @@
+package broker
+
+import "sync"
+
+// Memory is the default single-node Broker: publishes are fanned out
+// in-process to every local subscriber of a topic. It is the zero-scaling
+// backend used when no external message bus is configured.
+type Memory struct {
+	mu   sync.Mutex
+	subs map[string][]chan []byte
+}
+
+// NewMemory returns a ready-to-use in-process Broker.
+func NewMemory() *Memory {
+	return &Memory{subs: make(map[string][]chan []byte)}
+}
+
+// Publish implements Broker.
+func (m *Memory) Publish(topic string, msg []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, sub := range m.subs[topic] {
+		select {
+		case sub <- msg:
+		default:
+		}
+	}
+	return nil
+}
+
+// Subscribe implements Broker.
+func (m *Memory) Subscribe(topic string) (<-chan []byte, error) {
+	ch := make(chan []byte, 256)
+
+	m.mu.Lock()
+	m.subs[topic] = append(m.subs[topic], ch)
+	m.mu.Unlock()
+
+	return ch, nil
+}
+
+// Close implements Broker. Memory holds no external resources; it only
+// closes every channel handed out by Subscribe.
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for topic, subs := range m.subs {
+		for _, sub := range subs {
+			close(sub)
+		}
+		delete(m.subs, topic)
+	}
+	return nil
+}
+
+// MemoryPresence is the default single-node Presence: a plain in-memory
+// set, since a single process always knows exactly who is connected.
+type MemoryPresence struct {
+	mu    sync.Mutex
+	users map[string]bool
+}
+
+// NewMemoryPresence returns a ready-to-use in-process Presence set.
+func NewMemoryPresence() *MemoryPresence {
+	return &MemoryPresence{users: make(map[string]bool)}
+}
+
+// Mark implements Presence.
+func (p *MemoryPresence) Mark(userID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.users[userID] = true
+	return nil
+}
+
+// Remove implements Presence.
+func (p *MemoryPresence) Remove(userID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.users, userID)
+	return nil
+}
+
+// Users implements Presence.
+func (p *MemoryPresence) Users() ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	users := make([]string, 0, len(p.users))
+	for id := range p.users {
+		users = append(users, id)
+	}
+	return users, nil
+}
@@ -0,0 +1,122 @@
+package broker
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestMemoryPublishDeliversToSubscribers(t *testing.T) {
+	m := NewMemory()
+
+	ch, err := m.Subscribe("room1")
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	if err := m.Publish("room1", []byte("hello")); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	select {
+	case got := <-ch:
+		if string(got) != "hello" {
+			t.Fatalf("got %q, want %q", got, "hello")
+		}
+	default:
+		t.Fatal("subscriber did not receive published message")
+	}
+}
+
+func TestMemoryPublishIgnoresOtherTopics(t *testing.T) {
+	m := NewMemory()
+
+	ch, err := m.Subscribe("room1")
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	if err := m.Publish("room2", []byte("hello")); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	select {
+	case got := <-ch:
+		t.Fatalf("subscriber to a different topic received message: %q", got)
+	default:
+	}
+}
+
+func TestMemoryPublishDoesNotBlockOnFullSubscriber(t *testing.T) {
+	m := NewMemory()
+
+	ch, err := m.Subscribe("room1")
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	for i := 0; i < cap(ch)+1; i++ {
+		if err := m.Publish("room1", []byte("x")); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+}
+
+func TestMemoryPresenceMarkAndRemove(t *testing.T) {
+	p := NewMemoryPresence()
+
+	if err := p.Mark("alice"); err != nil {
+		t.Fatalf("Mark: %v", err)
+	}
+	if err := p.Mark("bob"); err != nil {
+		t.Fatalf("Mark: %v", err)
+	}
+
+	users, err := p.Users()
+	if err != nil {
+		t.Fatalf("Users: %v", err)
+	}
+	sort.Strings(users)
+	if want := []string{"alice", "bob"}; !equalStrings(users, want) {
+		t.Fatalf("got %v, want %v", users, want)
+	}
+
+	if err := p.Remove("alice"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+
+	users, err = p.Users()
+	if err != nil {
+		t.Fatalf("Users: %v", err)
+	}
+	if want := []string{"bob"}; !equalStrings(users, want) {
+		t.Fatalf("got %v, want %v", users, want)
+	}
+}
+
+func TestMemoryPresenceRemoveUnknownUserIsNoop(t *testing.T) {
+	p := NewMemoryPresence()
+
+	if err := p.Remove("nobody"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+
+	users, err := p.Users()
+	if err != nil {
+		t.Fatalf("Users: %v", err)
+	}
+	if len(users) != 0 {
+		t.Fatalf("got %v, want empty", users)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,95 @@
+package broker
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis is a Broker backed by Redis pub/sub. Every server instance that
+// subscribes to the same topic receives the same messages, so a message
+// published on one node reaches sockets connected to another.
+type Redis struct {
+	client *redis.Client
+	ctx    context.Context
+}
+
+// Dial connects to the Redis instance at addr and returns a Broker and
+// Presence sharing that connection, ready for use as the manager's backend.
+func Dial(addr string, presenceTTL time.Duration) (*Redis, *RedisPresence, error) {
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	ctx := context.Background()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		return nil, nil, err
+	}
+
+	r := &Redis{client: client, ctx: ctx}
+	return r, &RedisPresence{client: client, ctx: ctx, ttl: presenceTTL}, nil
+}
+
+// Publish implements Broker.
+func (r *Redis) Publish(topic string, msg []byte) error {
+	return r.client.Publish(r.ctx, topic, msg).Err()
+}
+
+// Subscribe implements Broker.
+func (r *Redis) Subscribe(topic string) (<-chan []byte, error) {
+	pubsub := r.client.Subscribe(r.ctx, topic)
+	if _, err := pubsub.Receive(r.ctx); err != nil {
+		return nil, err
+	}
+
+	out := make(chan []byte, 256)
+	go func() {
+		defer close(out)
+		for msg := range pubsub.Channel() {
+			out <- []byte(msg.Payload)
+		}
+	}()
+	return out, nil
+}
+
+// Close implements Broker.
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
+
+// presenceKey is the sorted set holding online users, scored by the Unix
+// timestamp at which their entry expires.
+const presenceKey = "chat:presence"
+
+// RedisPresence tracks online users across the cluster in a Redis sorted
+// set. Entries are scored by expiry rather than given a Redis TTL so that
+// Users can report everyone still within their window in a single call.
+type RedisPresence struct {
+	client *redis.Client
+	ctx    context.Context
+	ttl    time.Duration
+}
+
+// Mark implements Presence, refreshing userID's expiry to now+ttl.
+func (p *RedisPresence) Mark(userID string) error {
+	return p.client.ZAdd(p.ctx, presenceKey, redis.Z{
+		Score:  float64(time.Now().Add(p.ttl).Unix()),
+		Member: userID,
+	}).Err()
+}
+
+// Remove implements Presence.
+func (p *RedisPresence) Remove(userID string) error {
+	return p.client.ZRem(p.ctx, presenceKey, userID).Err()
+}
+
+// Users implements Presence, first dropping anyone whose expiry has
+// already lapsed (e.g. their ping ticker stopped without a clean
+// disconnect) before reporting who is left.
+func (p *RedisPresence) Users() ([]string, error) {
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	if err := p.client.ZRemRangeByScore(p.ctx, presenceKey, "-inf", now).Err(); err != nil {
+		return nil, err
+	}
+	return p.client.ZRange(p.ctx, presenceKey, 0, -1).Result()
+}
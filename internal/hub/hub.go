@@ -0,0 +1,91 @@
+// Package hub implements a concurrency-safe topic/subscriber registry used
+// to scope pub/sub traffic to the clients that asked for a given topic,
+// instead of fanning every message out to every connection.
+package hub
+
+import "sync"
+
+// Hub tracks, for each topic, the set of subscriber channels interested in
+// it. The zero value is not usable; construct with New.
+type Hub struct {
+	mu     sync.Mutex
+	topics map[string]map[chan []byte]bool
+}
+
+// New returns an empty Hub.
+func New() *Hub {
+	return &Hub{topics: make(map[string]map[chan []byte]bool)}
+}
+
+// Subscribe registers sub as a listener for topic.
+func (h *Hub) Subscribe(topic string, sub chan []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.topics[topic] == nil {
+		h.topics[topic] = make(map[chan []byte]bool)
+	}
+	h.topics[topic][sub] = true
+}
+
+// Unsubscribe removes sub from topic.
+func (h *Hub) Unsubscribe(topic string, sub chan []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.unsubscribeLocked(topic, sub)
+}
+
+// RemoveSubscriber removes sub from every topic it is a member of, e.g.
+// when the underlying connection has gone away.
+func (h *Hub) RemoveSubscriber(sub chan []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for topic := range h.topics {
+		h.unsubscribeLocked(topic, sub)
+	}
+}
+
+func (h *Hub) unsubscribeLocked(topic string, sub chan []byte) {
+	subs, ok := h.topics[topic]
+	if !ok {
+		return
+	}
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(h.topics, topic)
+	}
+}
+
+// Publish fans message out to every current subscriber of topic. A
+// subscriber whose buffer is full is skipped rather than blocking the
+// publisher; the caller owns eviction of dead subscribers.
+func (h *Hub) Publish(topic string, message []byte) {
+	h.mu.Lock()
+	subs := make([]chan []byte, 0, len(h.topics[topic]))
+	for sub := range h.topics[topic] {
+		subs = append(subs, sub)
+	}
+	h.mu.Unlock()
+
+	for _, sub := range subs {
+		select {
+		case sub <- message:
+		default:
+		}
+	}
+}
+
+// Topics returns the names of all topics that currently have at least one
+// subscriber.
+func (h *Hub) Topics() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	names := make([]string, 0, len(h.topics))
+	for name := range h.topics {
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,111 @@
+package hub
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPublishDeliversOnlyToSubscribers(t *testing.T) {
+	h := New()
+	inTopic := make(chan []byte, 1)
+	outOfTopic := make(chan []byte, 1)
+
+	h.Subscribe("room1", inTopic)
+	h.Publish("room1", []byte("hello"))
+
+	select {
+	case got := <-inTopic:
+		if string(got) != "hello" {
+			t.Fatalf("got %q, want %q", got, "hello")
+		}
+	default:
+		t.Fatal("subscriber did not receive published message")
+	}
+
+	select {
+	case got := <-outOfTopic:
+		t.Fatalf("non-subscriber received message: %q", got)
+	default:
+	}
+}
+
+func TestUnsubscribeStopsDelivery(t *testing.T) {
+	h := New()
+	sub := make(chan []byte, 1)
+
+	h.Subscribe("room1", sub)
+	h.Unsubscribe("room1", sub)
+	h.Publish("room1", []byte("hello"))
+
+	select {
+	case got := <-sub:
+		t.Fatalf("unsubscribed listener received message: %q", got)
+	default:
+	}
+}
+
+func TestRemoveSubscriberClearsAllTopics(t *testing.T) {
+	h := New()
+	sub := make(chan []byte, 1)
+
+	h.Subscribe("room1", sub)
+	h.Subscribe("room2", sub)
+	h.RemoveSubscriber(sub)
+
+	h.Publish("room1", []byte("a"))
+	h.Publish("room2", []byte("b"))
+
+	select {
+	case got := <-sub:
+		t.Fatalf("removed subscriber received message: %q", got)
+	default:
+	}
+
+	for _, topic := range h.Topics() {
+		t.Fatalf("expected no topics to remain, found %q", topic)
+	}
+}
+
+func TestConcurrentSubscribePublishUnsubscribe(t *testing.T) {
+	h := New()
+
+	const n = 50
+	subs := make([]chan []byte, n)
+	for i := range subs {
+		subs[i] = make(chan []byte, n)
+	}
+
+	var wg sync.WaitGroup
+	for _, sub := range subs {
+		wg.Add(1)
+		go func(sub chan []byte) {
+			defer wg.Done()
+			h.Subscribe("room1", sub)
+		}(sub)
+	}
+	wg.Wait()
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < n; i++ {
+			h.Publish("room1", []byte("tick"))
+		}
+		close(done)
+	}()
+
+	for _, sub := range subs {
+		wg.Add(1)
+		go func(sub chan []byte) {
+			defer wg.Done()
+			h.Unsubscribe("room1", sub)
+		}(sub)
+	}
+	wg.Wait()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("publish loop did not finish")
+	}
+}
@@ -1,48 +1,171 @@
 // main.go
-// In main.go we wire everything together: upgrade HTTP to WebSocket,
-// create a client with a UUID, register it with the manager,
-// and spin up the per-connection goroutines. We also start the manager loop and the HTTP server.
-// Keep CheckOrigin permissive only for local learning; in production lock it down.
+// In main.go we wire everything together: authenticate the handshake (see auth.go),
+// upgrade HTTP to WebSocket, register the client with the manager, and spin up the
+// per-connection goroutines. We also start the manager loop and the HTTP server.
+// See config.go for upgrader/buffer tuning and the Origin allowlist.
+//
+// The process shuts down gracefully on SIGINT/SIGTERM: new upgrades are rejected with 503,
+// the HTTP server is given a chance to finish in-flight requests, and the manager drains its
+// connected clients before main returns.
 
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true // In production, validate the origin here.
-	},
+// upgrader is built from serverConfig in main before the HTTP server starts.
+var upgrader websocket.Upgrader
+
+// serverCfg holds the settings loaded in main, read by wsHandler and
+// Client.write.
+var serverCfg serverConfig
+
+// shuttingDown is set once the server has begun its shutdown sequence, so
+// wsHandler can reject new upgrades instead of racing the manager's drain.
+var shuttingDown atomic.Bool
+
+// checkOrigin builds a CheckOrigin func for the upgrader. With no allowlist
+// configured it falls back to a same-origin check (gorilla's own safe
+// default) instead of unconditionally accepting every origin.
+func checkOrigin(allowedOrigins []string) func(*http.Request) bool {
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, o := range allowedOrigins {
+		allowed[o] = true
+	}
+
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		if len(allowed) > 0 {
+			return allowed[origin]
+		}
+
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
 }
 
 func wsHandler(w http.ResponseWriter, r *http.Request) {
+	if shuttingDown.Load() {
+		http.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+
+	userID, err := authenticate(r, serverCfg)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		http.Error(w, "upgrade failed", http.StatusBadRequest)
 		return
 	}
 
-	client := &Client{id: uuid.NewString(), socket: conn, send: make(chan []byte)}
+	// Without a configured JWT key, authenticate returns an empty userID:
+	// fall back to an anonymous per-connection id, as before.
+	id := userID
+	if id == "" {
+		id = uuid.NewString()
+	}
+
+	client := &Client{id: id, socket: conn, send: make(chan []byte, 256)}
+
+	// wg.Add must happen before the client can be observed by shutdown(),
+	// i.e. before register is sent: otherwise a handshake landing right as
+	// the manager enters shutdown() could race wg.Add against wg.Wait (Go's
+	// documented WaitGroup misuse), or block forever sending to a register
+	// channel nobody is receiving on anymore. The connection is already
+	// upgraded by this point, so on the losing side of that race we can only
+	// close it - there's no HTTP response left to write a 503 to.
+	manager.wg.Add(2)
+	if shuttingDown.Load() {
+		manager.wg.Add(-2)
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "server is shutting down"))
+		conn.Close()
+		return
+	}
 	manager.register <- client
 
-	go client.read()
-	go client.write()
+	go func() {
+		defer manager.wg.Done()
+		client.read()
+	}()
+	go func() {
+		defer manager.wg.Done()
+		client.write()
+	}()
+}
+
+// usersHandler reports who is online, via the configured Presence backend
+// so the answer is cluster-wide rather than limited to this node's sockets.
+func usersHandler(w http.ResponseWriter, r *http.Request) {
+	users, err := manager.presence.Users()
+	if err != nil {
+		http.Error(w, "failed to list online users", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(users)
 }
 
 func main() {
-	fmt.Println("starting server on :12345 ...")
-	go manager.start()
+	serverCfg = loadConfig()
+	upgrader = websocket.Upgrader{
+		ReadBufferSize:    serverCfg.readBufferSize,
+		WriteBufferSize:   serverCfg.writeBufferSize,
+		HandshakeTimeout:  serverCfg.handshakeTimeout,
+		Subprotocols:      serverCfg.subprotocols,
+		EnableCompression: serverCfg.enableCompression,
+		CheckOrigin:       checkOrigin(serverCfg.allowedOrigins),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	go manager.Run(ctx)
 
+	server := &http.Server{Addr: serverCfg.addr, Handler: nil}
 	http.HandleFunc("/ws", wsHandler)
+	http.HandleFunc("/users", usersHandler)
 	// Optional: serve statis Angular build under "/"
 	// http.Handle("/", http.FileServer(http.Dir("./static")))
 
-	if err := http.ListenAndServe(":12345", nil); err != nil {
-		panic(err)
+	go func() {
+		fmt.Println("starting server on", serverCfg.addr, "...")
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fmt.Println("server error:", err)
+		}
+	}()
+
+	<-ctx.Done()
+	shuttingDown.Store(true)
+	fmt.Println("shutting down...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		fmt.Println("server shutdown error:", err)
 	}
+
+	// http.Server.Shutdown doesn't know about hijacked websocket connections,
+	// so wait for the manager to finish draining them separately.
+	manager.wg.Wait()
 }
@@ -1,50 +1,206 @@
 // manager.go
 
-// central event loop. The manager handles client registration, unregistration, and message broadcasting.
+// central event loop. The manager handles client registration, unregistration, direct
+// messages, and topic-scoped pub/sub via the internal/hub registry.
 package main
 
-import "encoding/json"
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"go-chat-server/internal/broker"
+	"go-chat-server/internal/hub"
+)
+
+// presenceTTL is how long a presence entry stays valid without a refresh;
+// kept above pingPeriod, at which each connection's write loop refreshes
+// it, so a still-connected user doesn't lapse from /users between refreshes.
+const presenceTTL = 90 * time.Second
 
 var manager = ClientManager{
-	broadcast:  make(chan []byte),
-	register:   make(chan *Client),
-	unregister: make(chan *Client),
-	clients:    make(map[*Client]bool),
+	direct:       make(chan *Message),
+	publish:      make(chan topicMessage),
+	subscribe:    make(chan topicRequest),
+	unsubscribe:  make(chan topicRequest),
+	register:     make(chan *Client),
+	unregister:   make(chan *Client),
+	clients:      make(map[*Client]bool),
+	clientsByID:  make(map[string]map[*Client]bool),
+	topics:       hub.New(),
+	brokerTopics: make(map[string]bool),
+}
+
+func init() {
+	manager.broker, manager.presence = newBroker()
+	manager.ensureBrokerSubscription(generalTopic)
 }
 
-func (manager *ClientManager) start() {
+// newBroker selects the message bus backend: Redis when REDIS_ADDR is set,
+// so multiple server instances share broadcast traffic, or the in-process
+// default for a single node.
+func newBroker() (broker.Broker, broker.Presence) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		return broker.NewMemory(), broker.NewMemoryPresence()
+	}
+
+	b, presence, err := broker.Dial(addr, presenceTTL)
+	if err != nil {
+		fmt.Println("redis broker unavailable, falling back to in-process broker:", err)
+		return broker.NewMemory(), broker.NewMemoryPresence()
+	}
+	return b, presence
+}
+
+// Run is the manager's event loop. It blocks until ctx is cancelled, at
+// which point it closes every connected client's send channel and waits
+// for their read/write goroutines to exit before returning.
+func (manager *ClientManager) Run(ctx context.Context) {
 	for {
 		select {
+		case <-ctx.Done():
+			manager.shutdown()
+			return
+
 		case conn := <-manager.register:
 			manager.clients[conn] = true
-			msg, _ := json.Marshal(Message{Content: "New client connected"})
+			if manager.clientsByID[conn.id] == nil {
+				manager.clientsByID[conn.id] = make(map[*Client]bool)
+			}
+			manager.clientsByID[conn.id][conn] = true
+			manager.topics.Subscribe(generalTopic, conn.send)
+			manager.topics.Subscribe(dmTopic(conn.id), conn.send)
+			manager.ensureBrokerSubscription(dmTopic(conn.id))
+			manager.presence.Mark(conn.id)
+			msg, _ := json.Marshal(Message{Type: "join", Content: "New client connected"})
 			manager.send(msg, conn)
 
 		case conn := <-manager.unregister:
 			if _, ok := manager.clients[conn]; ok {
-				close(conn.send)
-				delete(manager.clients, conn)
-				msg, _ := json.Marshal(&Message{Content: "/A socket disconnected."})
+				manager.removeClient(conn)
+				msg, _ := json.Marshal(&Message{Type: "leave", Content: "/A socket disconnected."})
 				manager.send(msg, conn)
 			}
-		case message := <-manager.broadcast:
-			for conn := range manager.clients {
-				select {
-				case conn.send <- message:
-				default:
-					close(conn.send)
-					delete(manager.clients, conn)
-				}
-			}
+		case message := <-manager.direct:
+			manager.route(message)
+
+		case req := <-manager.subscribe:
+			manager.topics.Subscribe(req.topic, req.client.send)
+			manager.ensureBrokerSubscription(req.topic)
+
+		case req := <-manager.unsubscribe:
+			manager.topics.Unsubscribe(req.topic, req.client.send)
+
+		case msg := <-manager.publish:
+			manager.broker.Publish(msg.topic, msg.message)
+		}
+	}
+}
+
+// shutdown closes every connected client's send channel, which causes each
+// client's write() goroutine to send a CloseGoingAway frame and return, then
+// waits for all read/write goroutines to finish. It keeps draining
+// unregister while waiting so a read() goroutine woken by the resulting
+// connection close never blocks trying to report it.
+func (manager *ClientManager) shutdown() {
+	for conn := range manager.clients {
+		close(conn.send)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		manager.wg.Wait()
+		close(done)
+	}()
+
+	for {
+		select {
+		case <-manager.unregister:
+		case <-done:
+			return
 		}
 	}
 }
 
-// send broadcasts to all except the ignored client.
+// send broadcasts to all except the ignored client, evicting any
+// connection whose send buffer is full instead of blocking the manager
+// loop on a slow peer.
 func (m *ClientManager) send(message []byte, ignore *Client) {
 	for conn := range m.clients {
-		if conn != ignore {
-			conn.send <- message
+		if conn == ignore {
+			continue
+		}
+		select {
+		case conn.send <- message:
+		default:
+			m.removeClient(conn)
+		}
+	}
+}
+
+// dmTopic is the per-user topic a client subscribes to on connect, so a
+// direct message reaches every connection that user has open - on this
+// node or, via the Broker, any other node sharing the same backend.
+func dmTopic(userID string) string {
+	return dmTopicPrefix + userID
+}
+
+// route delivers message to its recipient and echoes it back to the
+// sender, by publishing on each user's topic rather than resolving
+// clientsByID locally: a recipient or sender connected to a different node
+// than the one that received this message is only reachable through the
+// Broker, the same way topic chat already is.
+func (m *ClientManager) route(message *Message) {
+	jsonMessage, _ := json.Marshal(message)
+
+	m.broker.Publish(dmTopic(message.Recipient), jsonMessage)
+	if message.Sender != message.Recipient {
+		m.broker.Publish(dmTopic(message.Sender), jsonMessage)
+	}
+}
+
+// removeClient evicts conn: it unsubscribes conn.send from every topic,
+// closes it, and removes conn from clients/clientsByID. The presence entry
+// for conn.id is only cleared once the user's last connection on this node
+// is gone, so a still-connected tab on this node isn't dropped from /users
+// when a sibling tab closes. Connections on other nodes are not counted.
+func (m *ClientManager) removeClient(conn *Client) {
+	m.topics.RemoveSubscriber(conn.send)
+	close(conn.send)
+	delete(m.clients, conn)
+
+	if conns := m.clientsByID[conn.id]; conns != nil {
+		delete(conns, conn)
+		if len(conns) == 0 {
+			delete(m.clientsByID, conn.id)
+			m.presence.Remove(conn.id)
 		}
 	}
 }
+
+// ensureBrokerSubscription subscribes the manager to topic on the Broker
+// exactly once, bridging anything published to it - by this node or any
+// other sharing the same backend - into the local topic hub so connected
+// clients receive it.
+func (manager *ClientManager) ensureBrokerSubscription(topic string) {
+	if manager.brokerTopics[topic] {
+		return
+	}
+	manager.brokerTopics[topic] = true
+
+	ch, err := manager.broker.Subscribe(topic)
+	if err != nil {
+		fmt.Println("broker subscribe failed for topic", topic, ":", err)
+		delete(manager.brokerTopics, topic)
+		return
+	}
+
+	go func() {
+		for msg := range ch {
+			manager.topics.Publish(topic, msg)
+		}
+	}()
+}
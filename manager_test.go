@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShutdownClosesClientsAndWaitsForDrain(t *testing.T) {
+	m := newTestManager()
+	m.unregister = make(chan *Client)
+
+	conn := registerTestClient(m, "user1")
+
+	m.wg.Add(1)
+	go func() {
+		// Simulate the client's read/write goroutines noticing the closed
+		// send channel and reporting back on unregister before exiting.
+		m.unregister <- conn
+		m.wg.Done()
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		m.shutdown()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("shutdown did not return once its goroutines finished draining")
+	}
+
+	select {
+	case _, ok := <-conn.send:
+		if ok {
+			t.Fatal("expected conn.send to be closed by shutdown")
+		}
+	default:
+		t.Fatal("expected conn.send to be closed (read would not block) by shutdown")
+	}
+}